@@ -8,6 +8,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
 	"time"
 
 	"github.com/olekukonko/tablewriter"
@@ -17,6 +19,7 @@ import (
 	"github.com/elastic/elastic-package/internal/cobraext"
 	"github.com/elastic/elastic-package/internal/configuration/locations"
 	"github.com/elastic/elastic-package/internal/install"
+	"github.com/elastic/elastic-package/internal/kubectl"
 	"github.com/elastic/elastic-package/internal/profile"
 )
 
@@ -26,6 +29,16 @@ const jsonFormat = "json"
 // tableFormat is the format for table output
 const tableFormat = "table"
 
+// textFormat is the format for human-readable unified diff output
+const textFormat = "text"
+
+// errProfilesDiffer is returned by "profiles diff" when it finds
+// differences, so the root command's executor can translate it into a
+// non-zero exit code without the command calling os.Exit itself. The diff
+// output has already been printed by the time this is returned, so it's
+// silenced from the usual "Error: ..." reporting.
+var errProfilesDiffer = errors.New("profiles have differences")
+
 func setupProfilesCommand() *cobraext.Command {
 	profilesLongDescription := `Use this command to add, remove, and manage multiple config profiles.
 	
@@ -136,11 +149,21 @@ User profiles can be configured with a "config.yml" file in the profile director
 			}
 			profileName := args[0]
 
-			_, err := profile.LoadProfile(profileName)
+			p, err := profile.LoadProfile(profileName)
 			if err != nil {
 				return fmt.Errorf("cannot use profile %q: %v", profileName, err)
 			}
 
+			kubeContext, err := p.KubernetesContext()
+			if err != nil {
+				return errors.Wrap(err, "error reading profile's kubernetes context")
+			}
+			if kubeContext != "" {
+				if err := kubectl.ValidateContext(kubeContext); err != nil {
+					return fmt.Errorf("profile %q is bound to an invalid kubernetes context: %w", profileName, err)
+				}
+			}
+
 			location, err := locations.NewLocationManager()
 			if err != nil {
 				return fmt.Errorf("error fetching profile: %w", err)
@@ -160,16 +183,314 @@ User profiles can be configured with a "config.yml" file in the profile director
 		},
 	}
 
+	profileSecretsCommand := setupProfileSecretsCommand()
+
+	profileDiffCommand := &cobra.Command{
+		Use:   "diff <profile-a> <profile-b>",
+		Short: "Compare the configuration of two profiles",
+		Long:  `Use this command to compare the configuration files of two profiles, for example to detect drift between a developer's local profile and one committed to a repository. It exits with a non-zero status if any differences are found, so it can be used in CI.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return errors.New("diff requires two profile names")
+			}
+
+			loc, err := locations.NewLocationManager()
+			if err != nil {
+				return errors.Wrap(err, "error fetching profile path")
+			}
+
+			report, err := profile.DiffProfiles(
+				filepath.Join(loc.ProfileDir(), args[0]),
+				filepath.Join(loc.ProfileDir(), args[1]),
+			)
+			if err != nil {
+				return errors.Wrap(err, "error comparing profiles")
+			}
+
+			format, err := cmd.Flags().GetString(cobraext.ProfileFormatFlagName)
+			if err != nil {
+				return cobraext.FlagParsingError(err, cobraext.ProfileFormatFlagName)
+			}
+
+			switch format {
+			case textFormat:
+				printDiffText(report)
+			case jsonFormat:
+				if err := printDiffJSON(report); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("format %s not supported", format)
+			}
+
+			if report.HasDifferences() {
+				cmd.SilenceUsage = true
+				cmd.SilenceErrors = true
+				return errProfilesDiffer
+			}
+			return nil
+		},
+	}
+	profileDiffCommand.Flags().String(cobraext.ProfileFormatFlagName, textFormat, cobraext.ProfileFormatFlagDescription)
+
+	profileExportCommand := &cobra.Command{
+		Use:   "export <profile>",
+		Short: "Export a profile as a portable archive",
+		Long:  `Use this command to package a profile as a gzipped tarball that can be copied to another machine or CI runner and restored with "profiles import".`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("export requires a profile name")
+			}
+			profileName := args[0]
+
+			output, err := cmd.Flags().GetString("output")
+			if err != nil {
+				return cobraext.FlagParsingError(err, "output")
+			}
+			if output == "" {
+				output = profileName + ".tar.gz"
+			}
+
+			stripSecrets, err := cmd.Flags().GetBool("strip-secrets")
+			if err != nil {
+				return cobraext.FlagParsingError(err, "strip-secrets")
+			}
+
+			f, err := os.Create(output)
+			if err != nil {
+				return errors.Wrapf(err, "error creating %q", output)
+			}
+			defer f.Close()
+
+			err = profile.ExportProfile(profileName, f, profile.ExportOptions{StripSecrets: stripSecrets})
+			if err != nil {
+				return errors.Wrapf(err, "error exporting profile %s", profileName)
+			}
+
+			fmt.Printf("Exported profile %s to %s.\n", profileName, output)
+			return nil
+		},
+	}
+	profileExportCommand.Flags().StringP("output", "o", "", "output archive path (defaults to <profile>.tar.gz)")
+	profileExportCommand.Flags().Bool("strip-secrets", false, "omit the profile's encrypted secrets from the archive")
+
+	profileImportCommand := &cobra.Command{
+		Use:   "import <file.tar.gz>",
+		Short: "Import a profile from a portable archive",
+		Long:  `Use this command to restore a profile archive created with "profiles export".`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("import requires an archive path")
+			}
+			archivePath := args[0]
+
+			newName, err := cmd.Flags().GetString("name")
+			if err != nil {
+				return cobraext.FlagParsingError(err, "name")
+			}
+
+			force, err := cmd.Flags().GetBool("force")
+			if err != nil {
+				return cobraext.FlagParsingError(err, "force")
+			}
+
+			f, err := os.Open(archivePath)
+			if err != nil {
+				return errors.Wrapf(err, "error opening %q", archivePath)
+			}
+			defer f.Close()
+
+			err = profile.ImportProfile(f, profile.ImportOptions{Name: newName, Force: force})
+			if err != nil {
+				return errors.Wrapf(err, "error importing profile from %s", archivePath)
+			}
+
+			fmt.Printf("Imported profile from %s.\n", archivePath)
+			return nil
+		},
+	}
+	profileImportCommand.Flags().String("name", "", "name to give the imported profile (defaults to the name recorded in the archive)")
+	profileImportCommand.Flags().Bool("force", false, "overwrite an existing profile with the same name")
+
+	profileSetContextCommand := &cobra.Command{
+		Use:   "set-context <profile> <context>",
+		Short: "Bind a Kubernetes context to a profile",
+		Long:  `Use this command to scope every "kubectl apply/delete" made on behalf of a profile to a specific Kubernetes context, preventing an agent from being deployed to the wrong cluster when switching profiles.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return errors.New("set-context requires a profile name and a Kubernetes context")
+			}
+
+			p, err := profile.LoadProfile(args[0])
+			if err != nil {
+				return fmt.Errorf("cannot use profile %q: %v", args[0], err)
+			}
+
+			if err := p.SetKubernetesContext(args[1]); err != nil {
+				return errors.Wrap(err, "error setting kubernetes context")
+			}
+
+			fmt.Printf("Profile %s now uses Kubernetes context %s.\n", args[0], args[1])
+			return nil
+		},
+	}
+
 	profileCommand.AddCommand(
 		profileNewCommand,
 		profileDeleteCommand,
 		profileListCommand,
 		profileUseCommand,
+		profileSecretsCommand,
+		profileDiffCommand,
+		profileExportCommand,
+		profileImportCommand,
+		profileSetContextCommand,
 	)
 
 	return cobraext.NewCommand(profileCommand, cobraext.ContextGlobal)
 }
 
+func setupProfileSecretsCommand() *cobra.Command {
+	profileSecretsCommand := &cobra.Command{
+		Use:   "secrets",
+		Short: "Manage a profile's encrypted secrets",
+		Long:  `Use this command to set, read, remove, and list credentials (Elasticsearch passwords, Kibana API keys, registry tokens, signing keys) stored encrypted in a profile's secrets.enc file.`,
+	}
+
+	profileSecretsSetCommand := &cobra.Command{
+		Use:   "set <profile> <name> <value>",
+		Short: "Set a secret",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 3 {
+				return errors.New("set requires a profile name, a secret name and a value")
+			}
+
+			p, err := profile.LoadProfile(args[0])
+			if err != nil {
+				return fmt.Errorf("cannot use profile %q: %v", args[0], err)
+			}
+
+			if err := p.SetSecret(args[1], args[2]); err != nil {
+				return errors.Wrap(err, "error setting secret")
+			}
+
+			fmt.Printf("Secret %s set in profile %s.\n", args[1], args[0])
+			return nil
+		},
+	}
+
+	profileSecretsGetCommand := &cobra.Command{
+		Use:   "get <profile> <name>",
+		Short: "Print a secret's value",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return errors.New("get requires a profile name and a secret name")
+			}
+
+			p, err := profile.LoadProfile(args[0])
+			if err != nil {
+				return fmt.Errorf("cannot use profile %q: %v", args[0], err)
+			}
+
+			value, err := p.Secret(args[1])
+			if err != nil {
+				return errors.Wrap(err, "error reading secret")
+			}
+
+			fmt.Println(value)
+			return nil
+		},
+	}
+
+	profileSecretsUnsetCommand := &cobra.Command{
+		Use:   "unset <profile> <name>",
+		Short: "Remove a secret",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return errors.New("unset requires a profile name and a secret name")
+			}
+
+			p, err := profile.LoadProfile(args[0])
+			if err != nil {
+				return fmt.Errorf("cannot use profile %q: %v", args[0], err)
+			}
+
+			if err := p.UnsetSecret(args[1]); err != nil {
+				return errors.Wrap(err, "error unsetting secret")
+			}
+
+			fmt.Printf("Secret %s removed from profile %s.\n", args[1], args[0])
+			return nil
+		},
+	}
+
+	profileSecretsListCommand := &cobra.Command{
+		Use:   "list <profile>",
+		Short: "List secret names",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("list requires a profile name")
+			}
+
+			p, err := profile.LoadProfile(args[0])
+			if err != nil {
+				return fmt.Errorf("cannot use profile %q: %v", args[0], err)
+			}
+
+			names, err := p.ListSecrets()
+			if err != nil {
+				return errors.Wrap(err, "error listing secrets")
+			}
+			if len(names) == 0 {
+				fmt.Println("There are no secrets in this profile yet.")
+				return nil
+			}
+
+			for _, name := range names {
+				fmt.Println(name)
+			}
+			return nil
+		},
+	}
+
+	profileSecretsCommand.AddCommand(
+		profileSecretsSetCommand,
+		profileSecretsGetCommand,
+		profileSecretsUnsetCommand,
+		profileSecretsListCommand,
+	)
+
+	return profileSecretsCommand
+}
+
+func printDiffText(report profile.DiffReport) {
+	if !report.HasDifferences() {
+		fmt.Println("No differences found.")
+		return
+	}
+
+	for _, f := range report.Files {
+		switch f.Status {
+		case profile.FileAdded:
+			fmt.Printf("Only in %s: %s\n", report.ProfileB, f.Path)
+		case profile.FileRemoved:
+			fmt.Printf("Only in %s: %s\n", report.ProfileA, f.Path)
+		case profile.FileModified:
+			fmt.Print(f.Diff)
+		}
+	}
+}
+
+func printDiffJSON(report profile.DiffReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "error marshalling diff report")
+	}
+
+	fmt.Println(string(data))
+	return nil
+}
+
 func formatJSON(profileList []profile.Metadata) error {
 	data, err := json.Marshal(profileList)
 	if err != nil {
@@ -185,13 +506,14 @@ func formatTable(profileList []profile.Metadata) error {
 	table := tablewriter.NewWriter(os.Stdout)
 	var profilesTable = profileToList(profileList)
 
-	table.SetHeader([]string{"Name", "Date Created", "User", "Version", "Path"})
+	table.SetHeader([]string{"Name", "Date Created", "User", "Version", "Path", "Has Secrets"})
 	table.SetHeaderColor(
 		twColor(tablewriter.Colors{tablewriter.Bold}),
 		twColor(tablewriter.Colors{tablewriter.Bold}),
 		twColor(tablewriter.Colors{tablewriter.Bold}),
 		twColor(tablewriter.Colors{tablewriter.Bold}),
 		twColor(tablewriter.Colors{tablewriter.Bold}),
+		twColor(tablewriter.Colors{tablewriter.Bold}),
 	)
 	table.SetColumnColor(
 		twColor(tablewriter.Colors{tablewriter.Bold, tablewriter.FgCyanColor}),
@@ -199,6 +521,7 @@ func formatTable(profileList []profile.Metadata) error {
 		tablewriter.Colors{},
 		tablewriter.Colors{},
 		tablewriter.Colors{},
+		tablewriter.Colors{},
 	)
 
 	table.SetAutoMergeCells(false)
@@ -212,7 +535,7 @@ func formatTable(profileList []profile.Metadata) error {
 func profileToList(profiles []profile.Metadata) [][]string {
 	var profileList [][]string
 	for _, profile := range profiles {
-		profileList = append(profileList, []string{profile.Name, profile.DateCreated.Format(time.RFC3339), profile.User, profile.Version, profile.Path})
+		profileList = append(profileList, []string{profile.Name, profile.DateCreated.Format(time.RFC3339), profile.User, profile.Version, profile.Path, strconv.FormatBool(profile.HasSecrets)})
 	}
 
 	return profileList
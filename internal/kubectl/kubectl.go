@@ -6,65 +6,186 @@ package kubectl
 
 import (
 	"bytes"
-	"os/exec"
-	"strings"
+	"fmt"
 
-	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/tools/clientcmd"
+
+	pkgerrors "github.com/pkg/errors"
 
 	"github.com/elastic/elastic-package/internal/logger"
 )
 
+// Config holds the Kubernetes client settings used to scope every request
+// made through this package to a single context and, optionally, a single
+// namespace.
+type Config struct {
+	// Context is the kubeconfig context to use. If empty, the current
+	// context from the user's kubeconfig is used.
+	Context string
+	// Namespace restricts resource operations to a given namespace. If
+	// empty, the namespace set in each resource manifest is used.
+	Namespace string
+}
+
+func (c Config) configFlags() *genericclioptions.ConfigFlags {
+	flags := genericclioptions.NewConfigFlags(true)
+	if c.Context != "" {
+		flags.Context = &c.Context
+	}
+	if c.Namespace != "" {
+		flags.Namespace = &c.Namespace
+	}
+	return flags
+}
+
 // CurrentContext function returns the selected Kubernetes context.
 func CurrentContext() (string, error) {
-	cmd := exec.Command("kubectl", "config", "current-context")
-	errOutput := new(bytes.Buffer)
-	cmd.Stderr = errOutput
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rawConfig, err := loadingRules.Load()
+	if err != nil {
+		return "", pkgerrors.Wrap(err, "failed to load kubeconfig")
+	}
+	if rawConfig.CurrentContext == "" {
+		return "", pkgerrors.New("kubeconfig doesn't define a current context")
+	}
+	return rawConfig.CurrentContext, nil
+}
 
-	logger.Debugf("output command: %s", cmd)
-	output, err := cmd.Output()
+// ValidateContext returns an error if context doesn't exist in the user's kubeconfig.
+func ValidateContext(context string) error {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rawConfig, err := loadingRules.Load()
 	if err != nil {
-		return "", errors.Wrapf(err, "kubectl command failed (stderr=%q)", errOutput.String())
+		return pkgerrors.Wrap(err, "failed to load kubeconfig")
+	}
+	if _, found := rawConfig.Contexts[context]; !found {
+		return fmt.Errorf("kubernetes context %q not found in kubeconfig", context)
 	}
-	return string(bytes.TrimSpace(output)), nil
+	return nil
 }
 
-func modifyKubernetesResources(action string, definitionPaths ...string) ([]byte, error) {
-	args := []string{action}
-	for _, definitionPath := range definitionPaths {
-		args = append(args, "-f")
-		args = append(args, definitionPath)
+// Apply function creates or updates the resources defined in the given manifests.
+func Apply(config Config, manifests ...[]byte) ([]unstructured.Unstructured, error) {
+	infos, err := resolveResources(config, manifests...)
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "apply failed to resolve resources")
+	}
+
+	var applied []unstructured.Unstructured
+	for _, info := range infos {
+		helper := resource.NewHelper(info.Client, info.Mapping)
+
+		logger.Debugf("apply resource: %s/%s", info.Mapping.Resource.Resource, info.Name)
+		obj, err := helper.Create(info.Namespace, true, info.Object)
+		if errors.IsAlreadyExists(err) {
+			obj, err = helper.Replace(info.Namespace, info.Name, true, info.Object)
+		}
+		if err != nil {
+			return nil, pkgerrors.Wrapf(err, "apply failed for resource %q", info.Name)
+		}
+
+		u, err := toUnstructured(obj)
+		if err != nil {
+			return nil, err
+		}
+		applied = append(applied, u)
 	}
+	return applied, nil
+}
 
-	if action != "delete" { // "delete" supports only '-o name'
-		args = append(args, "-o", "yaml")
+// Delete function removes the resources defined in the given manifests.
+func Delete(config Config, manifests ...[]byte) ([]unstructured.Unstructured, error) {
+	infos, err := resolveResources(config, manifests...)
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "delete failed to resolve resources")
 	}
 
-	cmd := exec.Command("kubectl", args...)
-	errOutput := new(bytes.Buffer)
-	cmd.Stderr = errOutput
+	var deleted []unstructured.Unstructured
+	for _, info := range infos {
+		helper := resource.NewHelper(info.Client, info.Mapping)
+
+		logger.Debugf("delete resource: %s/%s", info.Mapping.Resource.Resource, info.Name)
+		obj, err := helper.Delete(info.Namespace, info.Name)
+		if err != nil {
+			return nil, pkgerrors.Wrapf(err, "delete failed for resource %q", info.Name)
+		}
 
-	logger.Debugf("run command: %s", cmd)
-	output, err := cmd.Output()
+		u, err := toUnstructured(obj)
+		if err != nil {
+			return nil, err
+		}
+		deleted = append(deleted, u)
+	}
+	return deleted, nil
+}
+
+// Get function fetches the current state of the resources defined in the given manifests.
+func Get(config Config, manifests ...[]byte) ([]unstructured.Unstructured, error) {
+	infos, err := resolveResources(config, manifests...)
 	if err != nil {
-		return nil, errors.Wrapf(err, "kubectl apply failed (stderr=%q)", errOutput.String())
+		return nil, pkgerrors.Wrap(err, "get failed to resolve resources")
+	}
+
+	var fetched []unstructured.Unstructured
+	for _, info := range infos {
+		helper := resource.NewHelper(info.Client, info.Mapping)
+
+		obj, err := helper.Get(info.Namespace, info.Name)
+		if err != nil {
+			return nil, pkgerrors.Wrapf(err, "get failed for resource %q", info.Name)
+		}
+
+		u, err := toUnstructured(obj)
+		if err != nil {
+			return nil, err
+		}
+		fetched = append(fetched, u)
 	}
-	return output, nil
+	return fetched, nil
 }
 
-// applyKubernetesResourcesStdin applies a kubernetes manifest provided as stdin.
-// It returns the resources created as output and an error
-func applyKubernetesResourcesStdin(input string) ([]byte, error) {
-	// create kubectl apply command
-	kubectlCmd := exec.Command("kubectl", "apply", "-f", "-", "-o", "yaml")
-	//Stdin of kubectl command is the manifest provided
-	kubectlCmd.Stdin = strings.NewReader(input)
-	errOutput := new(bytes.Buffer)
-	kubectlCmd.Stderr = errOutput
-
-	logger.Debugf("run command: %s", kubectlCmd)
-	output, err := kubectlCmd.Output()
+// resolveResources loads the kubeconfig pointed at by config, builds a
+// discovery/RESTMapper-backed client and decodes the given manifests into
+// resource.Info values ready to be applied, deleted or fetched. Each
+// manifest is streamed straight out of memory, so callers never need to
+// write a rendered manifest to disk first.
+func resolveResources(config Config, manifests ...[]byte) ([]*resource.Info, error) {
+	flags := config.configFlags()
+
+	builder := resource.NewBuilder(flags).
+		Unstructured().
+		ContinueOnError()
+
+	for i, manifest := range manifests {
+		builder = builder.Stream(bytes.NewReader(manifest), fmt.Sprintf("manifest-%d", i))
+	}
+	builder = builder.Flatten()
+
+	if config.Namespace != "" {
+		builder = builder.NamespaceParam(config.Namespace).RequireNamespace()
+	}
+
+	result := builder.Do()
+	if err := result.Err(); err != nil {
+		return nil, err
+	}
+
+	return result.Infos()
+}
+
+func toUnstructured(obj runtime.Object) (unstructured.Unstructured, error) {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return *u, nil
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
 	if err != nil {
-		return nil, errors.Wrapf(err, "kubectl apply failed (stderr=%q)", errOutput.String())
+		return unstructured.Unstructured{}, pkgerrors.Wrap(err, "failed to convert object to unstructured")
 	}
-	return output, nil
+	return unstructured.Unstructured{Object: content}, nil
 }
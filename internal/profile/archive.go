@@ -0,0 +1,403 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package profile
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/elastic/elastic-package/internal/configuration/locations"
+	"github.com/elastic/elastic-package/internal/version"
+)
+
+// profileArchiveManifestName is the name of the manifest entry written as
+// the first file of every profile archive.
+const profileArchiveManifestName = "manifest.json"
+
+// profileArchivePayloadPrefix is the directory under which the profile's
+// own files are stored inside the archive.
+const profileArchivePayloadPrefix = "profile/"
+
+// profileMetadataFileName is the name of the profile metadata file inside a
+// profile directory.
+const profileMetadataFileName = "profile.json"
+
+// archiveManifest records enough information to validate and restore an
+// exported profile archive.
+type archiveManifest struct {
+	Version string `json:"version"`
+	Name    string `json:"name"`
+	Sha256  string `json:"sha256"`
+}
+
+// ExportOptions controls how a profile is serialized by ExportProfile.
+type ExportOptions struct {
+	// StripSecrets omits the profile's encrypted secrets.enc file from the archive.
+	StripSecrets bool
+}
+
+// ImportOptions controls how an archive produced by ExportProfile is
+// restored by ImportProfile.
+type ImportOptions struct {
+	// Name overrides the profile name recorded in the archive's manifest.
+	Name string
+	// Force allows importing over an existing profile directory.
+	Force bool
+}
+
+// ExportProfile writes profileName as a gzip-compressed tarball to w,
+// including a manifest that records the elastic-package version and a
+// sha256 of the payload so ImportProfile can validate it.
+func ExportProfile(profileName string, w io.Writer, opts ExportOptions) error {
+	profilePath, err := profileDirPath(profileName)
+	if err != nil {
+		return err
+	}
+
+	if info, err := os.Stat(profilePath); err != nil || !info.IsDir() {
+		return fmt.Errorf("profile %q not found", profileName)
+	}
+
+	sum, err := hashProfileDir(profilePath, opts.StripSecrets)
+	if err != nil {
+		return errors.Wrap(err, "error hashing profile directory")
+	}
+
+	manifestData, err := json.MarshalIndent(archiveManifest{
+		Version: version.CommitHash,
+		Name:    profileName,
+		Sha256:  sum,
+	}, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "error marshalling manifest")
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	if err := writeTarEntry(tw, profileArchiveManifestName, manifestData); err != nil {
+		return err
+	}
+
+	err = filepath.Walk(profilePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if opts.StripSecrets && info.Name() == secretsFileName {
+			return nil
+		}
+
+		rel, err := filepath.Rel(profilePath, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(filepath.Join(profileArchivePayloadPrefix, rel))
+
+		if err := tw.WriteHeader(header); err != nil {
+			return errors.Wrapf(err, "error writing header for %q", rel)
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return errors.Wrap(err, "error archiving profile directory")
+	}
+
+	if err := tw.Close(); err != nil {
+		return errors.Wrap(err, "error closing archive")
+	}
+	return gz.Close()
+}
+
+// ImportProfile reads a tarball produced by ExportProfile from r, validates
+// its manifest, and materializes it as a new profile, rewriting the
+// profile.json metadata (Path, User, DateCreated) for this host.
+func ImportProfile(r io.Reader, opts ImportOptions) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return errors.Wrap(err, "error reading archive")
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+
+	header, err := tr.Next()
+	if err != nil {
+		return errors.Wrap(err, "error reading archive manifest")
+	}
+	if header.Name != profileArchiveManifestName {
+		return fmt.Errorf("invalid profile archive: expected %q as first entry, found %q", profileArchiveManifestName, header.Name)
+	}
+
+	var manifest archiveManifest
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return errors.Wrap(err, "error parsing archive manifest")
+	}
+
+	profileName := manifest.Name
+	if opts.Name != "" {
+		profileName = opts.Name
+	}
+
+	destPath, err := profileDirPath(profileName)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(destPath); err == nil && !opts.Force {
+		return fmt.Errorf("profile %q already exists, use --force to overwrite", profileName)
+	}
+
+	// Extract into a staging directory inside the profiles root and only
+	// verify the sha256 once fully written, so a corrupt or malicious
+	// archive never touches the final profile directory.
+	stagingPath, err := os.MkdirTemp(filepath.Dir(destPath), ".import-"+profileName+"-")
+	if err != nil {
+		return errors.Wrap(err, "error creating staging directory")
+	}
+	defer os.RemoveAll(stagingPath)
+
+	if err := extractProfilePayload(tr, stagingPath); err != nil {
+		return errors.Wrap(err, "error extracting archive")
+	}
+
+	sum, err := hashProfileDir(stagingPath, false)
+	if err != nil {
+		return errors.Wrap(err, "error verifying imported profile")
+	}
+	if sum != manifest.Sha256 {
+		return fmt.Errorf("profile archive is corrupt: sha256 mismatch (expected %s, got %s)", manifest.Sha256, sum)
+	}
+
+	if err := rewriteImportedMetadata(stagingPath, profileName, destPath); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(destPath); err != nil {
+		return errors.Wrapf(err, "error removing existing profile %q", profileName)
+	}
+	if err := os.Rename(stagingPath, destPath); err != nil {
+		return errors.Wrap(err, "error installing imported profile")
+	}
+
+	return nil
+}
+
+// extractProfilePayload writes the "profile/"-prefixed entries of a tar
+// archive into destPath, rejecting any entry whose resolved path would
+// escape destPath (a "tar slip").
+func extractProfilePayload(tr *tar.Reader, destPath string) error {
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "error reading archive entry")
+		}
+
+		rel := strings.TrimPrefix(header.Name, profileArchivePayloadPrefix)
+		if rel == header.Name {
+			continue // entry outside the profile payload, ignore it
+		}
+
+		target, err := safeJoin(destPath, rel)
+		if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return errors.Wrapf(err, "error creating %q", target)
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return errors.Wrapf(err, "error creating %q", filepath.Dir(target))
+			}
+
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(header.Mode))
+			if err != nil {
+				return errors.Wrapf(err, "error creating %q", target)
+			}
+			_, err = io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return errors.Wrapf(err, "error writing %q", target)
+			}
+		}
+	}
+}
+
+// safeJoin joins rel onto root and guarantees the result is root or a
+// descendant of it, rejecting archive entries that try to escape it with
+// ".." segments or an absolute path.
+func safeJoin(root, rel string) (string, error) {
+	if filepath.IsAbs(filepath.FromSlash(rel)) {
+		return "", fmt.Errorf("archive entry %q escapes the profile directory", rel)
+	}
+	target := filepath.Join(root, filepath.FromSlash(rel))
+
+	cleanRoot := filepath.Clean(root)
+	if target != cleanRoot && !strings.HasPrefix(target, cleanRoot+string(os.PathSeparator)) {
+		return "", fmt.Errorf("archive entry %q escapes the profile directory", rel)
+	}
+	return target, nil
+}
+
+// rewriteImportedMetadata refreshes profile.json's Name, Path, User and
+// DateCreated fields for the current host after importing a profile that
+// may have been exported from another machine or user. workDir is where
+// profile.json is read from and rewritten (the staging directory); finalPath
+// is where the profile will live once installed, recorded as Metadata.Path.
+func rewriteImportedMetadata(workDir, profileName, finalPath string) error {
+	metadataPath := filepath.Join(workDir, profileMetadataFileName)
+
+	metadata, err := loadProfileMetadata(metadataPath)
+	if err != nil {
+		return errors.Wrap(err, "error reading imported profile metadata")
+	}
+
+	currentUser, err := user.Current()
+	if err != nil {
+		return errors.Wrap(err, "error fetching current user")
+	}
+
+	metadata.Name = profileName
+	metadata.Path = finalPath
+	metadata.User = currentUser.Username
+	metadata.DateCreated = time.Now()
+
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "error marshalling profile metadata")
+	}
+
+	return os.WriteFile(metadataPath, data, 0644)
+}
+
+func profileDirPath(name string) (string, error) {
+	if err := validateProfileName(name); err != nil {
+		return "", err
+	}
+
+	loc, err := locations.NewLocationManager()
+	if err != nil {
+		return "", errors.Wrap(err, "error fetching profile path")
+	}
+	return filepath.Join(loc.ProfileDir(), name), nil
+}
+
+// validateProfileName rejects profile names that could escape the profiles
+// directory once joined into a path - in particular "", ".", "..", names
+// containing a path separator, and absolute paths. This matters for
+// ImportProfile, where name can come from an untrusted archive's manifest.json
+// or the --name override, and is used to build both the staging and final
+// profile directory paths.
+func validateProfileName(name string) error {
+	if name == "" {
+		return errors.New("profile name must not be empty")
+	}
+	if name == "." || name == ".." {
+		return fmt.Errorf("invalid profile name %q", name)
+	}
+	if filepath.IsAbs(name) {
+		return fmt.Errorf("invalid profile name %q: must not be an absolute path", name)
+	}
+	if strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid profile name %q: must not contain path separators", name)
+	}
+	return nil
+}
+
+func writeTarEntry(tw *tar.Writer, name string, data []byte) error {
+	header := &tar.Header{
+		Name: name,
+		Mode: 0600,
+		Size: int64(len(data)),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return errors.Wrapf(err, "error writing %q header", name)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return errors.Wrapf(err, "error writing %q", name)
+	}
+	return nil
+}
+
+// hashProfileDir computes a sha256 over the sorted, relative-path-prefixed
+// contents of a profile directory, optionally skipping the encrypted
+// secrets file.
+func hashProfileDir(root string, stripSecrets bool) (string, error) {
+	var paths []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if stripSecrets && info.Name() == secretsFileName {
+			return nil
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(paths)
+
+	h := sha256.New()
+	for _, path := range paths {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\n", filepath.ToSlash(rel))
+
+		f, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,122 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package profile
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSafeJoin(t *testing.T) {
+	root := filepath.Join(string(filepath.Separator), "profiles", "default")
+
+	cases := []struct {
+		name    string
+		rel     string
+		wantErr bool
+	}{
+		{name: "plain file", rel: "config.yml"},
+		{name: "nested file", rel: filepath.Join("certs", "ca.pem")},
+		{name: "parent traversal", rel: filepath.Join("..", "..", "..", "tmp", "evil.txt"), wantErr: true},
+		{name: "absolute path", rel: filepath.Join(string(filepath.Separator), "tmp", "evil.txt"), wantErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			target, err := safeJoin(root, c.rel)
+			if c.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, filepath.Join(root, c.rel), target)
+		})
+	}
+}
+
+func TestExtractProfilePayloadRejectsTarSlip(t *testing.T) {
+	destPath := t.TempDir()
+
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	payload := []byte("evil content")
+	require.NoError(t, tw.WriteHeader(&tar.Header{
+		Name: profileArchivePayloadPrefix + "../../../../tmp/elastic-package-tar-slip-test.txt",
+		Mode: 0600,
+		Size: int64(len(payload)),
+	}))
+	_, err := tw.Write(payload)
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+
+	err = extractProfilePayload(tar.NewReader(buf), destPath)
+	require.Error(t, err)
+
+	_, statErr := os.Stat(filepath.Join(os.TempDir(), "elastic-package-tar-slip-test.txt"))
+	require.True(t, os.IsNotExist(statErr), "tar-slip entry must not be written outside destPath")
+}
+
+func TestValidateProfileName(t *testing.T) {
+	cases := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{name: "plain name", input: "local-dev"},
+		{name: "empty", input: "", wantErr: true},
+		{name: "dot", input: ".", wantErr: true},
+		{name: "dot-dot", input: "..", wantErr: true},
+		{name: "absolute path", input: filepath.Join(string(filepath.Separator), "etc", "passwd"), wantErr: true},
+		{name: "embedded separator", input: filepath.Join("..", "siblings"), wantErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateProfileName(c.input)
+			if c.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestProfileDirPathRejectsUnsafeNamesBeforeTouchingDisk(t *testing.T) {
+	// A malicious manifest.json "name" (or a --name override) must be
+	// rejected before it's ever used to build the staging/final profile
+	// directory paths in ImportProfile.
+	for _, name := range []string{"..", ".", "", filepath.Join("..", "siblings")} {
+		_, err := profileDirPath(name)
+		require.Error(t, err, "profileDirPath(%q) should reject the name", name)
+	}
+}
+
+func TestExtractProfilePayloadWritesRegularFiles(t *testing.T) {
+	destPath := t.TempDir()
+
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	payload := []byte("hello")
+	require.NoError(t, tw.WriteHeader(&tar.Header{
+		Name: profileArchivePayloadPrefix + "config.yml",
+		Mode: 0600,
+		Size: int64(len(payload)),
+	}))
+	_, err := tw.Write(payload)
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+
+	require.NoError(t, extractProfilePayload(tar.NewReader(buf), destPath))
+
+	content, err := os.ReadFile(filepath.Join(destPath, "config.yml"))
+	require.NoError(t, err)
+	require.Equal(t, payload, content)
+}
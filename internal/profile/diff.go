@@ -0,0 +1,208 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+	"gopkg.in/yaml.v3"
+)
+
+// FileDiffStatus describes how a compared file differs between two profiles.
+type FileDiffStatus string
+
+// Possible values of FileDiffStatus.
+const (
+	FileAdded    FileDiffStatus = "added"
+	FileRemoved  FileDiffStatus = "removed"
+	FileModified FileDiffStatus = "modified"
+)
+
+// FileDiff describes how a single configuration file differs between two profiles.
+type FileDiff struct {
+	Path   string         `json:"path"`
+	Status FileDiffStatus `json:"status"`
+	Diff   string         `json:"diff,omitempty"`
+}
+
+// DiffReport is the result of comparing the configuration files of two profiles.
+type DiffReport struct {
+	ProfileA string     `json:"profile_a"`
+	ProfileB string     `json:"profile_b"`
+	Files    []FileDiff `json:"files"`
+}
+
+// HasDifferences reports whether any configuration file differs between the two profiles.
+func (r DiffReport) HasDifferences() bool {
+	return len(r.Files) > 0
+}
+
+// volatileMetadataFields lists profile.json fields that are expected to
+// differ between any two profiles - because they change on every profile
+// creation (date_created, user, version), or because they are inherently
+// tied to a single host or profile name (path, name) - and that therefore
+// shouldn't be reported as drift.
+var volatileMetadataFields = map[string]bool{
+	"date_created": true,
+	"user":         true,
+	"version":      true,
+	"path":         true,
+	"name":         true,
+}
+
+// DiffProfiles compares the configuration files (kibana.yml, elasticsearch.yml,
+// snapshot.yml, package-registry config, profile.json, etc.) of two profile
+// directories and reports which files were added, removed or modified.
+// profile.json is normalized first so that volatileMetadataFields never show
+// up as a difference.
+func DiffProfiles(profileAPath, profileBPath string) (DiffReport, error) {
+	filesA, err := listConfigFiles(profileAPath)
+	if err != nil {
+		return DiffReport{}, errors.Wrapf(err, "error listing files in %q", profileAPath)
+	}
+	filesB, err := listConfigFiles(profileBPath)
+	if err != nil {
+		return DiffReport{}, errors.Wrapf(err, "error listing files in %q", profileBPath)
+	}
+
+	paths := map[string]bool{}
+	for p := range filesA {
+		paths[p] = true
+	}
+	for p := range filesB {
+		paths[p] = true
+	}
+
+	sortedPaths := make([]string, 0, len(paths))
+	for p := range paths {
+		sortedPaths = append(sortedPaths, p)
+	}
+	sort.Strings(sortedPaths)
+
+	report := DiffReport{ProfileA: profileAPath, ProfileB: profileBPath}
+	for _, relPath := range sortedPaths {
+		_, inA := filesA[relPath]
+		_, inB := filesB[relPath]
+
+		switch {
+		case inA && !inB:
+			report.Files = append(report.Files, FileDiff{Path: relPath, Status: FileRemoved})
+		case !inA && inB:
+			report.Files = append(report.Files, FileDiff{Path: relPath, Status: FileAdded})
+		default:
+			contentA, err := normalizedContent(filepath.Join(profileAPath, relPath))
+			if err != nil {
+				return DiffReport{}, err
+			}
+			contentB, err := normalizedContent(filepath.Join(profileBPath, relPath))
+			if err != nil {
+				return DiffReport{}, err
+			}
+			if contentA == contentB {
+				continue
+			}
+
+			diff, err := unifiedDiff(relPath, contentA, contentB)
+			if err != nil {
+				return DiffReport{}, errors.Wrapf(err, "error diffing %q", relPath)
+			}
+			report.Files = append(report.Files, FileDiff{Path: relPath, Status: FileModified, Diff: diff})
+		}
+	}
+
+	return report, nil
+}
+
+// listConfigFiles walks a profile directory and returns the relative paths
+// of its YAML/JSON configuration files. The encrypted secrets file is
+// skipped since its content is never diffed.
+func listConfigFiles(root string) (map[string]struct{}, error) {
+	files := map[string]struct{}{}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if info.Name() == secretsFileName {
+			return nil
+		}
+
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yml", ".yaml", ".json":
+		default:
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		files[rel] = struct{}{}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// normalizedContent reads a YAML/JSON file and re-serializes it in a
+// canonical form so that key ordering doesn't produce spurious diffs,
+// stripping volatileMetadataFields from profile.json along the way.
+func normalizedContent(path string) (string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "error reading %q", path)
+	}
+
+	var value interface{}
+	if err := yaml.Unmarshal(raw, &value); err != nil {
+		return "", errors.Wrapf(err, "error parsing %q", path)
+	}
+
+	if filepath.Base(path) == "profile.json" {
+		value = stripVolatileFields(value)
+	}
+
+	normalized, err := yaml.Marshal(value)
+	if err != nil {
+		return "", errors.Wrapf(err, "error normalizing %q", path)
+	}
+	return string(normalized), nil
+}
+
+func stripVolatileFields(value interface{}) interface{} {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return value
+	}
+
+	cleaned := map[string]interface{}{}
+	for k, v := range m {
+		if volatileMetadataFields[k] {
+			continue
+		}
+		cleaned[k] = v
+	}
+	return cleaned
+}
+
+func unifiedDiff(path, a, b string) (string, error) {
+	diff := difflib.UnifiedDiff{
+		A:        difflib.SplitLines(a),
+		B:        difflib.SplitLines(b),
+		FromFile: filepath.Join("a", path),
+		ToFile:   filepath.Join("b", path),
+		Context:  3,
+	}
+	return difflib.GetUnifiedDiffString(diff)
+}
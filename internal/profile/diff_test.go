@@ -0,0 +1,65 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeProfileFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+}
+
+func TestDiffProfilesIgnoresVolatileMetadata(t *testing.T) {
+	profileA := t.TempDir()
+	profileB := t.TempDir()
+
+	writeProfileFile(t, profileA, "profile.json", `{
+		"name": "local-dev",
+		"date_created": "2026-01-01T00:00:00Z",
+		"user": "alice",
+		"version": "1.2.3",
+		"path": "/home/alice/.elastic-package/profiles/local-dev"
+	}`)
+	writeProfileFile(t, profileB, "profile.json", `{
+		"name": "canonical",
+		"date_created": "2026-07-26T00:00:00Z",
+		"user": "ci",
+		"version": "1.3.0",
+		"path": "/home/ci/.elastic-package/profiles/canonical"
+	}`)
+
+	report, err := DiffProfiles(profileA, profileB)
+	require.NoError(t, err)
+	require.False(t, report.HasDifferences(), "only volatile metadata fields differ, should report no drift")
+}
+
+func TestDiffProfilesDetectsRealChanges(t *testing.T) {
+	profileA := t.TempDir()
+	profileB := t.TempDir()
+
+	writeProfileFile(t, profileA, "kibana.yml", "server.port: 5601\n")
+	writeProfileFile(t, profileB, "kibana.yml", "server.port: 5602\n")
+	writeProfileFile(t, profileA, "elasticsearch.yml", "cluster.name: dev\n")
+	writeProfileFile(t, profileB, "elasticsearch.yml", "cluster.name: dev\n")
+	writeProfileFile(t, profileB, "snapshot.yml", "repo: s3\n")
+
+	report, err := DiffProfiles(profileA, profileB)
+	require.NoError(t, err)
+	require.True(t, report.HasDifferences())
+
+	statuses := map[string]FileDiffStatus{}
+	for _, f := range report.Files {
+		statuses[f.Path] = f.Status
+	}
+	require.Equal(t, FileModified, statuses["kibana.yml"])
+	require.Equal(t, FileAdded, statuses["snapshot.yml"])
+	require.NotContains(t, statuses, "elasticsearch.yml")
+}
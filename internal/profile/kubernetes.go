@@ -0,0 +1,125 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package profile
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/elastic/elastic-package/internal/kubectl"
+)
+
+// profileConfigFileName is the name of the optional user-editable
+// configuration file kept in every profile directory.
+const profileConfigFileName = "config.yml"
+
+// kubernetesConfig is the subset of a profile's config.yml that binds it to
+// a specific Kubernetes context and namespace.
+type kubernetesConfig struct {
+	KubernetesContext   string `yaml:"kubernetes_context,omitempty"`
+	KubernetesNamespace string `yaml:"kubernetes_namespace,omitempty"`
+}
+
+// KubernetesContext returns the Kubernetes context bound to the profile, or
+// an empty string if none is set.
+func (p *Profile) KubernetesContext() (string, error) {
+	cfg, err := readKubernetesConfig(p.configPath())
+	if err != nil {
+		return "", err
+	}
+	return cfg.KubernetesContext, nil
+}
+
+// KubectlConfig returns the kubectl.Config that scopes every kubectl
+// operation performed on behalf of this profile to its bound Kubernetes
+// context and namespace.
+func (p *Profile) KubectlConfig() (kubectl.Config, error) {
+	cfg, err := readKubernetesConfig(p.configPath())
+	if err != nil {
+		return kubectl.Config{}, err
+	}
+	return kubectl.Config{
+		Context:   cfg.KubernetesContext,
+		Namespace: cfg.KubernetesNamespace,
+	}, nil
+}
+
+// SetKubernetesContext binds the profile to a Kubernetes context, after
+// validating that the context exists in the user's kubeconfig.
+func (p *Profile) SetKubernetesContext(context string) error {
+	if err := kubectl.ValidateContext(context); err != nil {
+		return err
+	}
+
+	cfg, err := readKubernetesConfig(p.configPath())
+	if err != nil {
+		return err
+	}
+	cfg.KubernetesContext = context
+
+	return writeKubernetesConfig(p.configPath(), cfg)
+}
+
+func (p *Profile) configPath() string {
+	return filepath.Join(p.ProfilePath, profileConfigFileName)
+}
+
+// readKubernetesConfig reads the kubernetes_context/kubernetes_namespace
+// keys out of the config.yml at path, returning a zero value if the file
+// doesn't exist yet.
+func readKubernetesConfig(path string) (kubernetesConfig, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return kubernetesConfig{}, nil
+	}
+	if err != nil {
+		return kubernetesConfig{}, errors.Wrapf(err, "error reading %q", profileConfigFileName)
+	}
+
+	var cfg kubernetesConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return kubernetesConfig{}, errors.Wrapf(err, "error parsing %q", profileConfigFileName)
+	}
+	return cfg, nil
+}
+
+// writeKubernetesConfig merges the kubernetes_context/kubernetes_namespace
+// keys into the config.yml at path, preserving any other keys already
+// present and deleting a key once its value is cleared.
+func writeKubernetesConfig(path string, cfg kubernetesConfig) error {
+	raw := map[string]interface{}{}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return errors.Wrapf(err, "error reading %q", profileConfigFileName)
+	default:
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return errors.Wrapf(err, "error parsing %q", profileConfigFileName)
+		}
+	}
+
+	if cfg.KubernetesContext != "" {
+		raw["kubernetes_context"] = cfg.KubernetesContext
+	} else {
+		delete(raw, "kubernetes_context")
+	}
+	if cfg.KubernetesNamespace != "" {
+		raw["kubernetes_namespace"] = cfg.KubernetesNamespace
+	} else {
+		delete(raw, "kubernetes_namespace")
+	}
+
+	out, err := yaml.Marshal(raw)
+	if err != nil {
+		return errors.Wrapf(err, "error marshalling %q", profileConfigFileName)
+	}
+
+	return os.WriteFile(path, out, 0644)
+}
@@ -0,0 +1,61 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadKubernetesConfigMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), profileConfigFileName)
+
+	cfg, err := readKubernetesConfig(path)
+	require.NoError(t, err)
+	require.Equal(t, kubernetesConfig{}, cfg)
+}
+
+func TestWriteKubernetesConfigPreservesUnrelatedKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), profileConfigFileName)
+	require.NoError(t, os.WriteFile(path, []byte("some_other_setting: value\n"), 0644))
+
+	require.NoError(t, writeKubernetesConfig(path, kubernetesConfig{
+		KubernetesContext:   "kind-kind",
+		KubernetesNamespace: "default",
+	}))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "some_other_setting: value")
+	require.Contains(t, string(data), "kubernetes_context: kind-kind")
+	require.Contains(t, string(data), "kubernetes_namespace: default")
+
+	cfg, err := readKubernetesConfig(path)
+	require.NoError(t, err)
+	require.Equal(t, "kind-kind", cfg.KubernetesContext)
+	require.Equal(t, "default", cfg.KubernetesNamespace)
+}
+
+func TestWriteKubernetesConfigDeletesClearedKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), profileConfigFileName)
+	require.NoError(t, os.WriteFile(path, []byte(
+		"some_other_setting: value\nkubernetes_context: kind-kind\nkubernetes_namespace: default\n",
+	), 0644))
+
+	require.NoError(t, writeKubernetesConfig(path, kubernetesConfig{}))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "some_other_setting: value")
+	require.NotContains(t, string(data), "kubernetes_context")
+	require.NotContains(t, string(data), "kubernetes_namespace")
+
+	cfg, err := readKubernetesConfig(path)
+	require.NoError(t, err)
+	require.Equal(t, kubernetesConfig{}, cfg)
+}
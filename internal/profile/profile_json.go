@@ -9,6 +9,7 @@ import (
 	"io"
 	"os"
 	"os/user"
+	"path/filepath"
 	"time"
 
 	"github.com/pkg/errors"
@@ -25,6 +26,7 @@ type Metadata struct {
 	User        string    `json:"user"`
 	Version     string    `json:"version"`
 	Path        string    `json:"path"`
+	HasSecrets  bool      `json:"has_secrets"`
 }
 
 // profileMetadataContent generates the content of the profile.json file.
@@ -50,6 +52,7 @@ func profileMetadataContent(applyCtx resource.Context, w io.Writer) error {
 		currentUser.Username,
 		version.CommitHash,
 		profilePath,
+		false,
 	}
 
 	enc := json.NewEncoder(w)
@@ -73,5 +76,11 @@ func loadProfileMetadata(path string) (Metadata, error) {
 	if err != nil {
 		return Metadata{}, errors.Wrapf(err, "error checking profile metadata file %q", path)
 	}
+
+	// HasSecrets reflects the current state of the profile directory rather
+	// than the value recorded when the profile was created, since secrets
+	// can be added or removed at any time.
+	metadata.HasSecrets = hasSecretsFile(filepath.Dir(path))
+
 	return metadata, nil
 }
@@ -0,0 +1,274 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package profile
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+// secretsFileName is the name of the encrypted secrets file kept in every
+// profile directory.
+const secretsFileName = "secrets.enc"
+
+// secretsFileVersion identifies the on-disk layout of secrets.enc:
+// version (1 byte) || salt (scryptSaltSize bytes) || nonce (secretboxNonceSize bytes) || ciphertext.
+const secretsFileVersion = byte(1)
+
+const (
+	scryptSaltSize          = 16
+	scryptKeySize           = 32
+	secretboxNonceSize      = 24
+	secretsHeaderSize       = 1 + scryptSaltSize + secretboxNonceSize
+	profilePassphraseEnvVar = "ELASTIC_PACKAGE_PROFILE_PASSPHRASE"
+)
+
+// ErrNoSecrets is returned when a profile doesn't have a secrets.enc file yet.
+var ErrNoSecrets = errors.New("profile doesn't have any secrets yet")
+
+// secretKeyCache caches the scrypt-derived key for each salt and passphrase
+// pair, so scrypt runs only once per pair within a process. The passphrase
+// itself is requested (or read from the environment) on every load or save.
+var (
+	secretKeyCacheMu sync.Mutex
+	secretKeyCache   = map[string]*[scryptKeySize]byte{}
+)
+
+// Secret returns the value of a secret stored in the profile's encrypted
+// secrets file.
+func (p *Profile) Secret(name string) (string, error) {
+	secrets, err := p.loadSecrets()
+	if err != nil {
+		return "", err
+	}
+
+	value, found := secrets[name]
+	if !found {
+		return "", fmt.Errorf("secret %q not found in profile %q", name, p.ProfileName)
+	}
+	return value, nil
+}
+
+// SetSecret stores (or replaces) a secret in the profile's encrypted secrets file.
+func (p *Profile) SetSecret(name, value string) error {
+	secrets, err := p.loadSecrets()
+	if err != nil && !errors.Is(err, ErrNoSecrets) {
+		return err
+	}
+	if secrets == nil {
+		secrets = map[string]string{}
+	}
+
+	secrets[name] = value
+	return p.saveSecrets(secrets)
+}
+
+// UnsetSecret removes a secret from the profile's encrypted secrets file.
+func (p *Profile) UnsetSecret(name string) error {
+	secrets, err := p.loadSecrets()
+	if err != nil {
+		return err
+	}
+
+	if _, found := secrets[name]; !found {
+		return fmt.Errorf("secret %q not found in profile %q", name, p.ProfileName)
+	}
+
+	delete(secrets, name)
+	return p.saveSecrets(secrets)
+}
+
+// ListSecrets returns the sorted names of the secrets stored in the profile.
+func (p *Profile) ListSecrets() ([]string, error) {
+	secrets, err := p.loadSecrets()
+	if errors.Is(err, ErrNoSecrets) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// HasSecrets reports whether the profile directory holds an encrypted secrets file.
+func (p *Profile) HasSecrets() bool {
+	return hasSecretsFile(p.ProfilePath)
+}
+
+func hasSecretsFile(profilePath string) bool {
+	_, err := os.Stat(filepath.Join(profilePath, secretsFileName))
+	return err == nil
+}
+
+func (p *Profile) secretsPath() string {
+	return filepath.Join(p.ProfilePath, secretsFileName)
+}
+
+func (p *Profile) loadSecrets() (map[string]string, error) {
+	encrypted, err := os.ReadFile(p.secretsPath())
+	if os.IsNotExist(err) {
+		return nil, ErrNoSecrets
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading secrets file")
+	}
+
+	passphrase, err := profilePassphrase()
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := decryptSecrets(encrypted, passphrase)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decrypting secrets file")
+	}
+
+	var secrets map[string]string
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, errors.Wrap(err, "error parsing decrypted secrets")
+	}
+	return secrets, nil
+}
+
+func (p *Profile) saveSecrets(secrets map[string]string) error {
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return errors.Wrap(err, "error marshalling secrets")
+	}
+
+	passphrase, err := profilePassphrase()
+	if err != nil {
+		return err
+	}
+
+	encrypted, err := encryptSecrets(plaintext, passphrase)
+	if err != nil {
+		return errors.Wrap(err, "error encrypting secrets")
+	}
+
+	if err := os.WriteFile(p.secretsPath(), encrypted, 0600); err != nil {
+		return errors.Wrap(err, "error writing secrets file")
+	}
+	return nil
+}
+
+// profilePassphrase returns the passphrase used to derive the secrets
+// encryption key, reading it from ELASTIC_PACKAGE_PROFILE_PASSPHRASE when
+// set, or prompting the user otherwise.
+func profilePassphrase() ([]byte, error) {
+	if passphrase := os.Getenv(profilePassphraseEnvVar); passphrase != "" {
+		return []byte(passphrase), nil
+	}
+
+	fmt.Fprint(os.Stderr, "Enter profile secrets passphrase: ")
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading passphrase")
+	}
+	return passphrase, nil
+}
+
+// deriveKey derives (and caches for the process lifetime) the secretbox key
+// for the given passphrase and salt using scrypt. The cache is keyed on both
+// the salt and the passphrase, so a wrong passphrase is never masked by a
+// previously cached derivation for the same salt.
+func deriveKey(passphrase, salt []byte) (*[scryptKeySize]byte, error) {
+	cacheKey := cacheKeyFor(passphrase, salt)
+
+	secretKeyCacheMu.Lock()
+	defer secretKeyCacheMu.Unlock()
+
+	if key, found := secretKeyCache[cacheKey]; found {
+		return key, nil
+	}
+
+	derived, err := scrypt.Key(passphrase, salt, 1<<15, 8, 1, scryptKeySize)
+	if err != nil {
+		return nil, errors.Wrap(err, "error deriving key")
+	}
+
+	var key [scryptKeySize]byte
+	copy(key[:], derived)
+	secretKeyCache[cacheKey] = &key
+	return &key, nil
+}
+
+// cacheKeyFor derives a cache identity from both the salt and the
+// passphrase, so that caching a key for one passphrase can never be
+// returned for a different (e.g. mistyped) passphrase against the same salt.
+func cacheKeyFor(passphrase, salt []byte) string {
+	sum := sha256.Sum256(append(append([]byte{}, salt...), passphrase...))
+	return string(sum[:])
+}
+
+func encryptSecrets(plaintext, passphrase []byte) ([]byte, error) {
+	salt := make([]byte, scryptSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, errors.Wrap(err, "error generating salt")
+	}
+
+	key, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	var nonce [secretboxNonceSize]byte
+	if _, err := io.ReadFull(rand.Reader, nonce[:]); err != nil {
+		return nil, errors.Wrap(err, "error generating nonce")
+	}
+
+	header := make([]byte, 0, secretsHeaderSize)
+	header = append(header, secretsFileVersion)
+	header = append(header, salt...)
+	header = append(header, nonce[:]...)
+
+	return secretbox.Seal(header, plaintext, &nonce, key), nil
+}
+
+func decryptSecrets(encrypted, passphrase []byte) ([]byte, error) {
+	if len(encrypted) < secretsHeaderSize {
+		return nil, errors.New("secrets file is truncated or corrupt")
+	}
+
+	version := encrypted[0]
+	if version != secretsFileVersion {
+		return nil, fmt.Errorf("unsupported secrets file version %d", version)
+	}
+
+	salt := encrypted[1 : 1+scryptSaltSize]
+	var nonce [secretboxNonceSize]byte
+	copy(nonce[:], encrypted[1+scryptSaltSize:secretsHeaderSize])
+	ciphertext := encrypted[secretsHeaderSize:]
+
+	key, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, ok := secretbox.Open(nil, ciphertext, &nonce, key)
+	if !ok {
+		return nil, errors.New("failed to decrypt secrets: wrong passphrase or corrupt file")
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,46 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package profile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptDecryptSecretsRoundTrip(t *testing.T) {
+	plaintext := []byte(`{"es_password":"changeme","kibana_api_key":"abc123"}`)
+	passphrase := []byte("correct horse battery staple")
+
+	encrypted, err := encryptSecrets(plaintext, passphrase)
+	require.NoError(t, err)
+	require.NotEqual(t, plaintext, encrypted)
+
+	decrypted, err := decryptSecrets(encrypted, passphrase)
+	require.NoError(t, err)
+	require.Equal(t, plaintext, decrypted)
+}
+
+func TestDecryptSecretsWrongPassphrase(t *testing.T) {
+	encrypted, err := encryptSecrets([]byte("top secret"), []byte("the right passphrase"))
+	require.NoError(t, err)
+
+	_, err = decryptSecrets(encrypted, []byte("the wrong passphrase"))
+	require.Error(t, err)
+}
+
+func TestDecryptSecretsTruncatedFile(t *testing.T) {
+	_, err := decryptSecrets([]byte("too short"), []byte("any passphrase"))
+	require.Error(t, err)
+}
+
+func TestDecryptSecretsUnsupportedVersion(t *testing.T) {
+	encrypted, err := encryptSecrets([]byte("payload"), []byte("passphrase"))
+	require.NoError(t, err)
+
+	encrypted[0] = secretsFileVersion + 1
+	_, err = decryptSecrets(encrypted, []byte("passphrase"))
+	require.Error(t, err)
+}